@@ -0,0 +1,137 @@
+package sensugo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authRefreshMargin is how far ahead of a cached bearer token's expiry the
+// service proactively fetches a replacement.
+const authRefreshMargin = 30 * time.Second
+
+// cachedToken is a bearer token obtained from the backend's /auth endpoint,
+// along with when it stops being usable.
+type cachedToken struct {
+	value  string
+	expiry time.Time
+}
+
+// authState holds the cached bearer token obtained via Username/Password;
+// embedded in Service so NewService doesn't need to initialize it.
+type authState struct {
+	tokenMu sync.Mutex
+	token   cachedToken
+}
+
+// resetToken discards any cached bearer token, forcing the next request to
+// fetch a fresh one. Called whenever the config is updated, since new
+// credentials invalidate whatever was cached for the old ones.
+func (s *Service) resetToken() {
+	s.tokenMu.Lock()
+	s.token = cachedToken{}
+	s.tokenMu.Unlock()
+}
+
+// authHeader resolves the current access token for c and formats it for
+// the Authorization header according to c.AuthScheme.
+func (s *Service) authHeader(c Config) (string, error) {
+	token, err := s.resolveToken(c)
+	if err != nil {
+		return "", err
+	}
+
+	switch c.AuthScheme {
+	case AuthSchemeKey:
+		return "Key " + token, nil
+	case AuthSchemeBearer:
+		return "Bearer " + token, nil
+	default:
+		return token, nil
+	}
+}
+
+// resolveToken returns the token to send, preferring, in order: TokenFile,
+// TokenEnv (if the variable is non-empty), an automatically refreshed
+// Username/Password bearer token, and finally the static Token.
+func (s *Service) resolveToken(c Config) (string, error) {
+	if c.TokenFile != "" {
+		data, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file: %v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if c.TokenEnv != "" {
+		if v := os.Getenv(c.TokenEnv); v != "" {
+			return v, nil
+		}
+	}
+	if c.Username != "" && c.Password != "" {
+		return s.refreshedToken(c)
+	}
+	return c.Token, nil
+}
+
+// refreshedToken returns the cached bearer token if it is still comfortably
+// within its expiry, refreshing it against the backend otherwise.
+func (s *Service) refreshedToken(c Config) (string, error) {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+
+	if s.token.value != "" && time.Now().Add(authRefreshMargin).Before(s.token.expiry) {
+		return s.token.value, nil
+	}
+
+	tok, expiry, err := s.fetchAuthToken(c)
+	if err != nil {
+		if s.token.value != "" {
+			s.diag.Error("failed to refresh Sensu Go auth token, reusing cached token", err)
+			return s.token.value, nil
+		}
+		return "", err
+	}
+
+	s.token = cachedToken{value: tok, expiry: expiry}
+	return tok, nil
+}
+
+type authResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// fetchAuthToken obtains a new bearer token from the backend's /auth
+// endpoint using HTTP basic auth, per the Sensu Go authentication API.
+func (s *Service) fetchAuthToken(c Config) (string, time.Time, error) {
+	req, err := http.NewRequest("GET", strings.TrimRight(c.URL, "/")+"/auth", nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return "", time.Time{}, fmt.Errorf("auth request returned non 2xx status code (%d)", resp.StatusCode)
+	}
+
+	var auth authResponse
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode auth response: %v", err)
+	}
+
+	expiry := time.Now().Add(time.Hour)
+	if auth.ExpiresAt > 0 {
+		expiry = time.Unix(auth.ExpiresAt, 0)
+	}
+	return auth.AccessToken, expiry, nil
+}
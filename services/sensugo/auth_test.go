@@ -0,0 +1,158 @@
+package sensugo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveToken_StaticByDefault(t *testing.T) {
+	c := Config{Token: "static-token"}
+	s := NewService(c, &testDiagnostic{})
+
+	tok, err := s.resolveToken(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tok != "static-token" {
+		t.Fatalf("got %q, want static-token", tok)
+	}
+}
+
+func TestResolveToken_EnvOverridesStatic(t *testing.T) {
+	t.Setenv("SENSUGO_TEST_TOKEN", "env-token")
+
+	c := Config{Token: "static-token", TokenEnv: "SENSUGO_TEST_TOKEN"}
+	s := NewService(c, &testDiagnostic{})
+
+	tok, err := s.resolveToken(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tok != "env-token" {
+		t.Fatalf("got %q, want env-token", tok)
+	}
+}
+
+func TestResolveToken_FileOverridesEnv(t *testing.T) {
+	t.Setenv("SENSUGO_TEST_TOKEN", "env-token")
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	c := Config{Token: "static-token", TokenEnv: "SENSUGO_TEST_TOKEN", TokenFile: path}
+	s := NewService(c, &testDiagnostic{})
+
+	tok, err := s.resolveToken(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tok != "file-token" {
+		t.Fatalf("got %q, want file-token", tok)
+	}
+}
+
+func TestAuthHeader_FormatsPerScheme(t *testing.T) {
+	s := NewService(Config{}, &testDiagnostic{})
+
+	cases := []struct {
+		scheme string
+		want   string
+	}{
+		{"", "abc"},
+		{AuthSchemeToken, "abc"},
+		{AuthSchemeKey, "Key abc"},
+		{AuthSchemeBearer, "Bearer abc"},
+	}
+	for _, tc := range cases {
+		c := Config{Token: "abc", AuthScheme: tc.scheme}
+		got, err := s.authHeader(c)
+		if err != nil {
+			t.Fatalf("scheme %q: unexpected error: %v", tc.scheme, err)
+		}
+		if got != tc.want {
+			t.Fatalf("scheme %q: got %q, want %q", tc.scheme, got, tc.want)
+		}
+	}
+}
+
+func TestRefreshedToken_CachesUntilNearExpiry(t *testing.T) {
+	var authCalls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authCalls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"bearer-token","expires_at":%d}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer srv.Close()
+
+	c := Config{URL: srv.URL, Username: "user", Password: "pass"}
+	s := NewService(c, &testDiagnostic{})
+
+	tok1, err := s.resolveToken(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tok2, err := s.resolveToken(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tok1 != "bearer-token" || tok2 != "bearer-token" {
+		t.Fatalf("got %q / %q, want bearer-token", tok1, tok2)
+	}
+	if authCalls != 1 {
+		t.Fatalf("expected exactly one /auth request, got %d", authCalls)
+	}
+}
+
+func TestRefreshedToken_RefetchesAfterExpiry(t *testing.T) {
+	var authCalls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authCalls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"bearer-token-%d","expires_at":%d}`, authCalls, time.Now().Add(authRefreshMargin/2).Unix())
+	}))
+	defer srv.Close()
+
+	c := Config{URL: srv.URL, Username: "user", Password: "pass"}
+	s := NewService(c, &testDiagnostic{})
+
+	if _, err := s.resolveToken(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.resolveToken(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if authCalls != 2 {
+		t.Fatalf("expected a refetch once the cached token is within the refresh margin, got %d calls", authCalls)
+	}
+}
+
+func TestResetToken_ForcesRefetch(t *testing.T) {
+	var authCalls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authCalls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"bearer-token","expires_at":%d}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer srv.Close()
+
+	c := Config{URL: srv.URL, Username: "user", Password: "pass"}
+	s := NewService(c, &testDiagnostic{})
+
+	if _, err := s.resolveToken(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.resetToken()
+	if _, err := s.resolveToken(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if authCalls != 2 {
+		t.Fatalf("expected resetToken to force a refetch, got %d calls", authCalls)
+	}
+}
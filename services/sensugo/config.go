@@ -1,6 +1,41 @@
 package sensugo
 
-import "errors"
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+	"github.com/influxdata/kapacitor/tlsconfig"
+)
+
+const (
+	DefaultTimeout              = toml.Duration(30 * time.Second)
+	DefaultMaxRetries           = 3
+	DefaultRetryInitialInterval = toml.Duration(500 * time.Millisecond)
+	DefaultRetryMaxInterval     = toml.Duration(30 * time.Second)
+	DefaultMaxIdleConns         = 10
+	DefaultWorkers              = 4
+	DefaultQueueSize            = 1000
+
+	// QueuePolicyBlock blocks the caller until there is room in the
+	// delivery queue.
+	QueuePolicyBlock = "block"
+	// QueuePolicyDropOldest drops the oldest queued event to make room for
+	// a new one rather than blocking the caller.
+	QueuePolicyDropOldest = "drop-oldest"
+
+	// AuthSchemeToken sends the token verbatim as the Authorization header,
+	// matching Sensu Go's legacy access-token auth.
+	AuthSchemeToken = "token"
+	// AuthSchemeKey sends the token as a Sensu Go 6+ API key:
+	// "Authorization: Key <token>".
+	AuthSchemeKey = "key"
+	// AuthSchemeBearer sends the token as an OAuth-style bearer token:
+	// "Authorization: Bearer <token>".
+	AuthSchemeBearer = "bearer"
+)
 
 type Config struct {
 	// Whether Sensu integration is enabled.
@@ -9,19 +44,92 @@ type Config struct {
 	URL string `toml:"url" override:"url"`
 	// Sensu Go token
 	Token string `toml:"token" override:"token"`
+	// How to format the Authorization header: "token" (default), "key", or
+	// "bearer".
+	AuthScheme string `toml:"auth-scheme" override:"auth-scheme"`
+	// If set, the token is read from this environment variable instead of
+	// Token, re-read on every request.
+	TokenEnv string `toml:"token-env" override:"token-env"`
+	// If set, the token is read from this file instead of Token, re-read on
+	// every request; takes precedence over TokenEnv.
+	TokenFile string `toml:"token-file" override:"token-file"`
+	// If Username and Password are set, a bearer token is instead obtained
+	// and refreshed automatically against the backend's /auth endpoint.
+	Username string `toml:"username" override:"username"`
+	Password string `toml:"password" override:"password,redact"`
 	// Default Sensu Go namespace
 	Namespace string `toml:"namespace" override:"namespace"`
 	// The sensu handlers to use
 	Handlers []string `toml:"handlers" override:"handlers"`
+
+	// Timeout for the HTTP request to the Sensu Go backend.
+	Timeout toml.Duration `toml:"timeout" override:"timeout"`
+	// Maximum number of additional attempts made after a failed POST.
+	MaxRetries int `toml:"max-retries" override:"max-retries"`
+	// Initial backoff interval between retries.
+	RetryInitialInterval toml.Duration `toml:"retry-initial-interval" override:"retry-initial-interval"`
+	// Maximum backoff interval between retries.
+	RetryMaxInterval toml.Duration `toml:"retry-max-interval" override:"retry-max-interval"`
+	// Maximum idle HTTP connections to keep open to the backend.
+	MaxIdleConns int `toml:"max-idle-conns" override:"max-idle-conns"`
+
+	// Skip TLS certificate verification when talking to the backend.
+	InsecureSkipVerify bool `toml:"insecure-skip-verify" override:"insecure-skip-verify"`
+	// Path to a PEM encoded CA file used to verify the backend's certificate.
+	CACert string `toml:"ca-cert" override:"ca-cert"`
+	// Path to a PEM encoded client certificate used for mutual TLS.
+	ClientCert string `toml:"client-cert" override:"client-cert"`
+	// Path to a PEM encoded client private key used for mutual TLS.
+	ClientKey string `toml:"client-key" override:"client-key"`
+
+	// Number of workers delivering queued events to the backend concurrently.
+	Workers int `toml:"workers" override:"workers"`
+	// Maximum number of events to buffer for delivery.
+	QueueSize int `toml:"queue-size" override:"queue-size"`
+	// How to handle an Alert call when the queue is full: "block" or
+	// "drop-oldest". Defaults to "block".
+	QueuePolicy string `toml:"queue-policy" override:"queue-policy"`
+	// Window within which events for the same entity/check are coalesced
+	// down to the most recent one before being queued for delivery. If
+	// zero, events are queued for delivery immediately.
+	FlushInterval toml.Duration `toml:"flush-interval" override:"flush-interval"`
 }
 
 func NewConfig() Config {
-	return Config{}
+	return Config{
+		Timeout:              DefaultTimeout,
+		MaxRetries:           DefaultMaxRetries,
+		RetryInitialInterval: DefaultRetryInitialInterval,
+		RetryMaxInterval:     DefaultRetryMaxInterval,
+		MaxIdleConns:         DefaultMaxIdleConns,
+		Workers:              DefaultWorkers,
+		QueueSize:            DefaultQueueSize,
+		QueuePolicy:          QueuePolicyBlock,
+	}
 }
 
 func (c Config) Validate() error {
 	if c.Enabled && c.URL == "" {
 		return errors.New("must specify backend URL")
 	}
+	if c.MaxRetries < 0 {
+		return errors.New("max-retries must not be negative")
+	}
+	switch c.QueuePolicy {
+	case "", QueuePolicyBlock, QueuePolicyDropOldest:
+	default:
+		return fmt.Errorf("invalid queue-policy %q", c.QueuePolicy)
+	}
+	switch c.AuthScheme {
+	case "", AuthSchemeToken, AuthSchemeKey, AuthSchemeBearer:
+	default:
+		return fmt.Errorf("invalid auth-scheme %q", c.AuthScheme)
+	}
 	return nil
 }
+
+// TLSConfig builds the *tls.Config to use for connections to the Sensu Go
+// backend, based on the configured CA/client certificates.
+func (c Config) TLSConfig() (*tls.Config, error) {
+	return tlsconfig.Create(c.CACert, c.ClientCert, c.ClientKey, c.InsecureSkipVerify)
+}
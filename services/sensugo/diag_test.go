@@ -0,0 +1,53 @@
+package sensugo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/influxdata/kapacitor/keyvalue"
+)
+
+// testDiagnostic is a minimal Diagnostic used by tests to observe counters
+// and errors without a real diagnostic service.
+type testDiagnostic struct {
+	mu sync.Mutex
+
+	errors   []error
+	enqueued int
+	dropped  int
+	sent     int
+}
+
+func (d *testDiagnostic) WithContext(ctx ...keyvalue.T) Diagnostic { return d }
+
+func (d *testDiagnostic) Error(msg string, err error, kvs ...keyvalue.T) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.errors = append(d.errors, err)
+}
+
+func (d *testDiagnostic) EventEnqueued() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.enqueued++
+}
+
+func (d *testDiagnostic) EventDropped() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dropped++
+}
+
+func (d *testDiagnostic) EventSent() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sent++
+}
+
+func (d *testDiagnostic) SendLatency(time.Duration) {}
+
+func (d *testDiagnostic) counts() (enqueued, dropped, sent int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.enqueued, d.dropped, d.sent
+}
@@ -0,0 +1,96 @@
+package sensugo
+
+import (
+	"strings"
+
+	"github.com/influxdata/kapacitor/alert"
+)
+
+const (
+	MetricFormatNone       = "none"
+	MetricFormatPrometheus = "prometheus"
+	MetricFormatInfluxDB   = "influxdb"
+	MetricFormatGraphite   = "graphite"
+)
+
+// Metrics is the Sensu Go check metrics payload.
+type Metrics struct {
+	Points []MetricPoint `json:"points"`
+}
+
+// MetricPoint is a single Sensu Go metric point.
+type MetricPoint struct {
+	Name      string      `json:"name"`
+	Tags      []MetricTag `json:"tags,omitempty"`
+	Value     float64     `json:"value"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// MetricTag is a name/value pair attached to a MetricPoint.
+type MetricTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// buildMetrics translates the numeric fields of an alert event's data into
+// Sensu Go metric points, tagged with the event's tags. It returns nil if
+// format disables metrics or there are no numeric fields to report.
+func buildMetrics(format string, data alert.Data, timestamp int64) *Metrics {
+	if format == "" || format == MetricFormatNone || len(data.Fields) == 0 {
+		return nil
+	}
+
+	tags := make([]MetricTag, 0, len(data.Tags))
+	for name, value := range data.Tags {
+		tags = append(tags, MetricTag{Name: name, Value: value})
+	}
+
+	points := make([]MetricPoint, 0, len(data.Fields))
+	for name, value := range data.Fields {
+		v, ok := toFloat64(value)
+		if !ok {
+			continue
+		}
+		points = append(points, MetricPoint{
+			Name:      metricName(format, name),
+			Tags:      tags,
+			Value:     v,
+			Timestamp: timestamp,
+		})
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	return &Metrics{Points: points}
+}
+
+// metricName adjusts a field name for the target metric_format; Graphite
+// paths can't contain spaces, while Prometheus/InfluxDB carry the field
+// name as-is alongside the separate tags array.
+func metricName(format, name string) string {
+	if format == MetricFormatGraphite {
+		return strings.ReplaceAll(name, " ", "_")
+	}
+	return name
+}
+
+// toFloat64 converts the numeric types that can appear in alert.Data.Fields
+// to float64, reporting false for anything else (e.g. strings, bools).
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,74 @@
+package sensugo
+
+import (
+	"testing"
+
+	"github.com/influxdata/kapacitor/alert"
+)
+
+func TestBuildMetrics_FormatNoneReturnsNil(t *testing.T) {
+	data := alert.Data{Fields: map[string]interface{}{"value": 1.0}}
+	if m := buildMetrics(MetricFormatNone, data, 0); m != nil {
+		t.Fatalf("expected nil metrics, got %+v", m)
+	}
+}
+
+func TestBuildMetrics_NoFieldsReturnsNil(t *testing.T) {
+	if m := buildMetrics(MetricFormatInfluxDB, alert.Data{}, 0); m != nil {
+		t.Fatalf("expected nil metrics for empty fields, got %+v", m)
+	}
+}
+
+func TestBuildMetrics_NonNumericFieldsAreSkipped(t *testing.T) {
+	data := alert.Data{
+		Fields: map[string]interface{}{"value": 42, "label": "not-a-number"},
+		Tags:   map[string]string{"host": "a"},
+	}
+
+	m := buildMetrics(MetricFormatInfluxDB, data, 100)
+	if m == nil || len(m.Points) != 1 {
+		t.Fatalf("expected exactly one numeric point, got %+v", m)
+	}
+
+	p := m.Points[0]
+	if p.Name != "value" || p.Value != 42 || p.Timestamp != 100 {
+		t.Fatalf("unexpected point: %+v", p)
+	}
+	if len(p.Tags) != 1 || p.Tags[0].Name != "host" || p.Tags[0].Value != "a" {
+		t.Fatalf("unexpected tags: %+v", p.Tags)
+	}
+}
+
+func TestBuildMetrics_GraphiteNameIsSanitized(t *testing.T) {
+	data := alert.Data{Fields: map[string]interface{}{"cpu usage": 1.5}}
+
+	m := buildMetrics(MetricFormatGraphite, data, 0)
+	if m == nil || len(m.Points) != 1 {
+		t.Fatalf("expected one point, got %+v", m)
+	}
+	if m.Points[0].Name != "cpu_usage" {
+		t.Fatalf("got name %q, want cpu_usage", m.Points[0].Name)
+	}
+}
+
+func TestToFloat64_SupportedAndUnsupportedTypes(t *testing.T) {
+	cases := []struct {
+		in     interface{}
+		want   float64
+		wantOk bool
+	}{
+		{1.5, 1.5, true},
+		{float32(2.5), 2.5, true},
+		{42, 42, true},
+		{int64(43), 43, true},
+		{uint64(44), 44, true},
+		{"nope", 0, false},
+		{true, 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := toFloat64(tc.in)
+		if ok != tc.wantOk || (ok && got != tc.want) {
+			t.Fatalf("toFloat64(%#v) = (%v, %v), want (%v, %v)", tc.in, got, ok, tc.want, tc.wantOk)
+		}
+	}
+}
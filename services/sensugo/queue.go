@@ -0,0 +1,222 @@
+package sensugo
+
+import (
+	"sync"
+	"time"
+)
+
+// queuedEvent is a PostEvent awaiting asynchronous delivery, along with the
+// key used to coalesce bursts of updates to the same entity/check.
+type queuedEvent struct {
+	key   string
+	event *PostEvent
+}
+
+// initQueue prepares the delivery queue and pending-coalesce map so Alert
+// can be called safely even before Open starts the worker pool.
+func (s *Service) initQueue(c Config) {
+	s.queueMu.Lock()
+	s.queue = make(chan *queuedEvent, queueSizeFor(c))
+	s.queueMu.Unlock()
+	s.pending = make(map[string]*queuedEvent)
+}
+
+func queueSizeFor(c Config) int {
+	if c.QueueSize > 0 {
+		return c.QueueSize
+	}
+	return DefaultQueueSize
+}
+
+// restartWorkers stops the current generation of delivery workers and the
+// coalescer (if any are running), resizes the queue if c.QueueSize has
+// changed, and starts a fresh generation sized from c. It is used both by
+// Open and by Update, so that changes to Workers/QueueSize/FlushInterval
+// made through a live config reload actually take effect.
+func (s *Service) restartWorkers(c Config) {
+	s.stopWorkers()
+
+	s.queueMu.Lock()
+	if queueSize := queueSizeFor(c); cap(s.queue) != queueSize {
+		old, resized := s.queue, make(chan *queuedEvent, queueSize)
+	drain:
+		for {
+			select {
+			case qe := <-old:
+				select {
+				case resized <- qe:
+				default:
+					s.diag.EventDropped()
+				}
+			default:
+				break drain
+			}
+		}
+		s.queue = resized
+	}
+	queue := s.queue
+	stopCh := make(chan struct{})
+	s.stopCh = stopCh
+	s.queueMu.Unlock()
+
+	workers := c.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	for i := 0; i < workers; i++ {
+		s.workersWG.Add(1)
+		go s.deliverLoop(queue, stopCh)
+	}
+
+	// The coalescer always runs, independent of whether FlushInterval is
+	// currently set, so that enabling it later via Update doesn't leave
+	// anything already sitting in s.pending stranded.
+	s.workersWG.Add(1)
+	go s.coalesceLoop(stopCh)
+}
+
+// stopWorkers signals the current worker pool and coalescing goroutine to
+// shut down, draining any events still queued, and waits for them to exit.
+func (s *Service) stopWorkers() {
+	s.queueMu.RLock()
+	stopCh := s.stopCh
+	s.queueMu.RUnlock()
+
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	s.workersWG.Wait()
+}
+
+func (s *Service) deliverLoop(queue chan *queuedEvent, stopCh chan struct{}) {
+	defer s.workersWG.Done()
+	for {
+		select {
+		case qe := <-queue:
+			s.deliver(qe.event)
+		case <-stopCh:
+			// Drain whatever is already queued before exiting so events
+			// accepted before shutdown are not silently lost.
+			for {
+				select {
+				case qe := <-queue:
+					s.deliver(qe.event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// coalesceMinTick bounds how often the coalescer wakes up to check the
+// current FlushInterval, so toggling it on via Update is noticed promptly
+// instead of being pinned to whatever interval was in effect at startup.
+const coalesceMinTick = 100 * time.Millisecond
+
+func (s *Service) coalesceLoop(stopCh chan struct{}) {
+	defer s.workersWG.Done()
+	timer := time.NewTimer(coalesceMinTick)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			s.flushPending()
+			interval := time.Duration(s.config().FlushInterval)
+			if interval <= 0 {
+				interval = coalesceMinTick
+			}
+			timer.Reset(interval)
+		case <-stopCh:
+			s.flushPending()
+			return
+		}
+	}
+}
+
+// flushPending moves every currently pending, coalesced event onto the
+// delivery queue.
+func (s *Service) flushPending() {
+	s.pendingMu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]*queuedEvent)
+	s.pendingMu.Unlock()
+
+	for _, qe := range pending {
+		s.submit(qe)
+	}
+}
+
+// submit places qe on the delivery queue, applying the configured
+// full-queue policy. Even under the "block" policy the wait is bounded by
+// c.Timeout, so a stalled backend can't wedge the caller forever.
+func (s *Service) submit(qe *queuedEvent) {
+	c := s.config()
+
+	s.queueMu.RLock()
+	queue, stopCh := s.queue, s.stopCh
+	s.queueMu.RUnlock()
+
+	select {
+	case queue <- qe:
+		s.diag.EventEnqueued()
+		return
+	default:
+	}
+
+	if c.QueuePolicy == QueuePolicyDropOldest {
+		select {
+		case <-queue:
+			s.diag.EventDropped()
+		default:
+		}
+
+		select {
+		case queue <- qe:
+			s.diag.EventEnqueued()
+		default:
+			s.diag.EventDropped()
+		}
+		return
+	}
+
+	timeout := time.Duration(c.Timeout)
+	if timeout <= 0 {
+		timeout = time.Duration(DefaultTimeout)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case queue <- qe:
+		s.diag.EventEnqueued()
+	case <-timer.C:
+		s.diag.EventDropped()
+		s.diag.Error("dropped Sensu Go event, queue stayed full past timeout", nil)
+	case <-stopCh:
+		s.diag.EventDropped()
+	}
+}
+
+func (s *Service) deliver(event *PostEvent) {
+	start := time.Now()
+	if err := s.doPost(event); err != nil {
+		s.diag.Error("failed to POST to Sensu Go", err)
+		return
+	}
+	s.diag.EventSent()
+	s.diag.SendLatency(time.Since(start))
+}
+
+// queueState is embedded in Service to hold the worker pool and coalescing
+// state; kept separate from Service's other fields for readability.
+type queueState struct {
+	queueMu   sync.RWMutex
+	queue     chan *queuedEvent
+	stopCh    chan struct{}
+	workersWG sync.WaitGroup
+
+	pendingMu sync.Mutex
+	pending   map[string]*queuedEvent
+}
@@ -0,0 +1,113 @@
+package sensugo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+)
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within %v", timeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSubmit_DropOldestPolicyMakesRoomForNewest(t *testing.T) {
+	diag := &testDiagnostic{}
+	c := Config{QueuePolicy: QueuePolicyDropOldest, QueueSize: 1}
+	s := NewService(c, diag)
+
+	s.submit(&queuedEvent{key: "a", event: &PostEvent{}})
+	s.submit(&queuedEvent{key: "b", event: &PostEvent{}})
+
+	enqueued, dropped, _ := diag.counts()
+	if enqueued != 2 {
+		t.Fatalf("got %d enqueued, want 2", enqueued)
+	}
+	if dropped != 1 {
+		t.Fatalf("got %d dropped, want 1", dropped)
+	}
+
+	select {
+	case qe := <-s.queue:
+		if qe.key != "b" {
+			t.Fatalf("got queued key %q, want b (the newest)", qe.key)
+		}
+	default:
+		t.Fatal("expected the newest event to be queued")
+	}
+}
+
+func TestSubmit_BlockPolicyTimesOutInsteadOfHanging(t *testing.T) {
+	diag := &testDiagnostic{}
+	c := Config{QueuePolicy: QueuePolicyBlock, QueueSize: 1, Timeout: toml.Duration(20 * time.Millisecond)}
+	s := NewService(c, diag)
+
+	// No worker pool is running, so nothing drains the queue; fill it
+	// directly so submit's fast path can't succeed.
+	s.queue <- &queuedEvent{key: "fill", event: &PostEvent{}}
+
+	done := make(chan struct{})
+	go func() {
+		s.submit(&queuedEvent{key: "blocked", event: &PostEvent{}})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("submit did not return promptly once the configured timeout elapsed")
+	}
+
+	_, dropped, _ := diag.counts()
+	if dropped != 1 {
+		t.Fatalf("got %d dropped, want 1", dropped)
+	}
+}
+
+func TestAlert_BeforeOpenDoesNotPanic(t *testing.T) {
+	c := Config{Enabled: true, FlushInterval: toml.Duration(time.Hour)}
+	s := NewService(c, &testDiagnostic{})
+
+	if err := s.Alert(AlertRequest{Check: "check", Entity: "entity"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUpdate_EnablingFlushIntervalLaterStillDeliversPendingEvents(t *testing.T) {
+	var posts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := Config{Enabled: true, URL: srv.URL, QueueSize: DefaultQueueSize, Workers: 1}
+	s := NewService(c, &testDiagnostic{})
+	if err := s.Open(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	c.FlushInterval = toml.Duration(20 * time.Millisecond)
+	if err := s.Update([]interface{}{c}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := s.Alert(AlertRequest{Check: "check", Entity: "entity"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitFor(t, time.Second, func() bool {
+		return atomic.LoadInt32(&posts) > 0
+	})
+}
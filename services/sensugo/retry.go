@@ -0,0 +1,50 @@
+package sensugo
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// backoffDuration returns a jittered exponential backoff duration for the
+// given attempt (1-indexed), capped at max.
+func backoffDuration(attempt int, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		base = time.Duration(DefaultRetryInitialInterval)
+	}
+	if max <= 0 {
+		max = time.Duration(DefaultRetryMaxInterval)
+	}
+
+	d := base * time.Duration(int64(1)<<uint(attempt-1))
+	if d <= 0 || d > max {
+		d = max
+	}
+
+	jittered := time.Duration(rand.Int63n(int64(d)) + int64(d)/2)
+	if jittered > max {
+		jittered = max
+	}
+	return jittered
+}
+
+// parseRetryAfter parses the value of a Retry-After response header, which
+// may be given either as a number of seconds or an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
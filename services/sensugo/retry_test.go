@@ -0,0 +1,63 @@
+package sensugo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffDuration_NeverExceedsMax(t *testing.T) {
+	max := 2 * time.Second
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := backoffDuration(attempt, 100*time.Millisecond, max)
+		if d < 0 || d > max {
+			t.Fatalf("attempt %d: backoff %v out of bounds [0, %v]", attempt, d, max)
+		}
+	}
+}
+
+func TestBackoffDuration_UsesDefaultsWhenUnset(t *testing.T) {
+	d := backoffDuration(1, 0, 0)
+	if d < 0 || d > time.Duration(DefaultRetryMaxInterval) {
+		t.Fatalf("backoff %v exceeds default max %v", d, time.Duration(DefaultRetryMaxInterval))
+	}
+}
+
+func TestParseRetryAfter_Empty(t *testing.T) {
+	if d := parseRetryAfter(""); d != 0 {
+		t.Fatalf("got %v, want 0", d)
+	}
+}
+
+func TestParseRetryAfter_Seconds(t *testing.T) {
+	if d := parseRetryAfter("5"); d != 5*time.Second {
+		t.Fatalf("got %v, want 5s", d)
+	}
+}
+
+func TestParseRetryAfter_NegativeSeconds(t *testing.T) {
+	if d := parseRetryAfter("-5"); d != 0 {
+		t.Fatalf("got %v, want 0 for a negative value", d)
+	}
+}
+
+func TestParseRetryAfter_FutureHTTPDate(t *testing.T) {
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	d := parseRetryAfter(future)
+	if d <= 0 || d > 10*time.Second {
+		t.Fatalf("got %v, want roughly 10s", d)
+	}
+}
+
+func TestParseRetryAfter_PastHTTPDate(t *testing.T) {
+	past := time.Now().Add(-10 * time.Second).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(past); d != 0 {
+		t.Fatalf("got %v, want 0 for a date already in the past", d)
+	}
+}
+
+func TestParseRetryAfter_Garbage(t *testing.T) {
+	if d := parseRetryAfter("not-a-valid-value"); d != 0 {
+		t.Fatalf("got %v, want 0 for unparsable input", d)
+	}
+}
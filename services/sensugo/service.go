@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/influxdata/kapacitor/alert"
@@ -18,11 +19,27 @@ import (
 type Diagnostic interface {
 	WithContext(ctx ...keyvalue.T) Diagnostic
 	Error(msg string, err error, kvs ...keyvalue.T)
+
+	// EventEnqueued is called each time an event is accepted onto the
+	// delivery queue.
+	EventEnqueued()
+	// EventDropped is called each time an event is discarded because the
+	// delivery queue was full.
+	EventDropped()
+	// EventSent is called each time an event is successfully delivered to
+	// the Sensu Go backend.
+	EventSent()
+	// SendLatency reports how long a successful delivery took.
+	SendLatency(d time.Duration)
 }
 
 type Service struct {
 	configValue atomic.Value
+	clientValue atomic.Value
 	diag        Diagnostic
+
+	queueState
+	authState
 }
 
 func NewService(c Config, d Diagnostic) *Service {
@@ -30,14 +47,22 @@ func NewService(c Config, d Diagnostic) *Service {
 		diag: d,
 	}
 	s.configValue.Store(c)
+	s.setClient(c)
+	// The queue and pending-coalesce map are ready as soon as the Service
+	// exists, so Alert/Test can be called safely even before Open starts
+	// the worker pool that drains them.
+	s.initQueue(c)
 	return s
 }
 
 func (s *Service) Open() error {
+	s.setClient(s.config())
+	s.restartWorkers(s.config())
 	return nil
 }
 
 func (s *Service) Close() error {
+	s.stopWorkers()
 	return nil
 }
 
@@ -45,6 +70,29 @@ func (s *Service) config() Config {
 	return s.configValue.Load().(Config)
 }
 
+func (s *Service) client() *http.Client {
+	return s.clientValue.Load().(*http.Client)
+}
+
+// setClient (re)builds the shared HTTP client used for requests to the
+// Sensu Go backend from c's timeout, connection pool, and TLS settings.
+func (s *Service) setClient(c Config) {
+	transport := &http.Transport{
+		MaxIdleConns:        c.MaxIdleConns,
+		MaxIdleConnsPerHost: c.MaxIdleConns,
+	}
+	if tlsCfg, err := c.TLSConfig(); err != nil {
+		s.diag.Error("failed to create TLS config for Sensu Go, using defaults", err)
+	} else {
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	s.clientValue.Store(&http.Client{
+		Timeout:   time.Duration(c.Timeout),
+		Transport: transport,
+	})
+}
+
 func (s *Service) Update(newConfig []interface{}) error {
 	if l := len(newConfig); l != 1 {
 		return fmt.Errorf("expected only one new config object, got %d", l)
@@ -53,6 +101,12 @@ func (s *Service) Update(newConfig []interface{}) error {
 		return fmt.Errorf("expected config object to be of type %T, got %T", c, newConfig[0])
 	} else {
 		s.configValue.Store(c)
+		s.setClient(c)
+		s.resetToken()
+		// Reconcile the worker pool/queue against the new Workers/
+		// QueueSize/FlushInterval, rather than leaving them pinned to
+		// whatever was in effect at the first Open.
+		s.restartWorkers(c)
 	}
 	return nil
 }
@@ -86,26 +140,75 @@ func (s *Service) Test(options interface{}) error {
 	if !ok {
 		return fmt.Errorf("unexpected options type %T", options)
 	}
-	return s.Alert(
-		o.Check,
-		o.Entity,
-		o.Message,
-		o.Namespace,
-		o.Handlers,
-		o.Labels,
-		o.Level,
-	)
+	return s.Alert(AlertRequest{
+		Check:     o.Check,
+		Entity:    o.Entity,
+		Message:   o.Message,
+		Namespace: o.Namespace,
+		Handlers:  o.Handlers,
+		Labels:    o.Labels,
+		Level:     o.Level,
+	})
+}
+
+// AlertRequest carries everything needed to build and queue a single Sensu
+// Go event, gathered up so Alert doesn't need a growing positional
+// parameter list.
+type AlertRequest struct {
+	Check     string
+	Entity    string
+	Message   string
+	Namespace string
+	Handlers  []string
+	Labels    map[string]string
+	Level     alert.Level
+
+	Annotations     map[string]string
+	Interval        int
+	Subscriptions   []string
+	ProxyEntityName string
+	RoundRobin      bool
+	TTL             int
+
+	// MetricFormat, Fields, and Tags are used to populate the check's
+	// metrics.points from the alert's numeric data, if any.
+	MetricFormat string
+	Fields       map[string]interface{}
+	Tags         map[string]string
 }
 
-func (s *Service) Alert(check, entity, message, namespace string, handlers []string, labels map[string]string, level alert.Level) error {
+// Alert builds a Sensu Go event from req and queues it for asynchronous
+// delivery; it does not block on the HTTP request. Delivery failures are
+// reported through the diagnostic interface rather than returned here.
+func (s *Service) Alert(req AlertRequest) error {
 	c := s.config()
 
 	if !c.Enabled {
 		return errors.New("service is not enabled")
 	}
 
+	event := buildPostEvent(c, req)
+
+	qe := &queuedEvent{
+		key:   req.Namespace + "|" + req.Entity + "|" + req.Check,
+		event: event,
+	}
+
+	if time.Duration(c.FlushInterval) > 0 {
+		s.pendingMu.Lock()
+		s.pending[qe.key] = qe
+		s.pendingMu.Unlock()
+		return nil
+	}
+
+	s.submit(qe)
+	return nil
+}
+
+// buildPostEvent translates an alert into the Sensu Go event payload.
+func buildPostEvent(c Config, req AlertRequest) *PostEvent {
 	var status int
-	switch level {
+	switch req.Level {
 	case alert.OK:
 		status = 0
 	case alert.Info:
@@ -123,61 +226,110 @@ func (s *Service) Alert(check, entity, message, namespace string, handlers []str
 	event := &PostEvent{}
 
 	event.Entity.EntityClass = "proxy"
-	event.Entity.Metadata.Name = entity
+	event.Entity.Metadata.Name = req.Entity
 
-	if namespace != "" {
-		event.Entity.Metadata.Namespace = namespace
+	if req.Namespace != "" {
+		event.Entity.Metadata.Namespace = req.Namespace
 	} else {
 		event.Entity.Metadata.Namespace = c.Namespace
 	}
-	event.Entity.Metadata.Labels = labels
-	event.Check.Output = message
+	event.Entity.Metadata.Labels = req.Labels
+
+	event.Check.Output = req.Message
 	event.Check.Status = status
-	event.Check.Metadata.Name = check
-	event.Check.Metadata.Labels = labels
+	event.Check.Metadata.Name = req.Check
+	event.Check.Metadata.Labels = req.Labels
+	event.Check.Metadata.Annotations = req.Annotations
 	event.Check.Issued = now.Unix()
 	event.Check.Executed = now.Unix()
-
-	if len(handlers) > 0 {
-		event.Check.Handlers = handlers
+	event.Check.Interval = req.Interval
+	event.Check.Subscriptions = req.Subscriptions
+	event.Check.ProxyEntityName = req.ProxyEntityName
+	event.Check.RoundRobin = req.RoundRobin
+	event.Check.TTL = req.TTL
+
+	if len(req.Handlers) > 0 {
+		event.Check.Handlers = req.Handlers
 	} else {
 		event.Check.Handlers = c.Handlers
 	}
 
-	data, err := json.Marshal(event)
+	event.Metrics = buildMetrics(req.MetricFormat, alert.Data{Fields: req.Fields, Tags: req.Tags}, now.Unix())
+
+	return event
+}
 
+// doPost delivers a single event to the Sensu Go backend synchronously,
+// retrying on network errors and 429/5xx responses with exponential
+// backoff, honoring any Retry-After header.
+func (s *Service) doPost(event *PostEvent) error {
+	c := s.config()
+
+	data, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", c.URL, bytes.NewReader(data))
+	authHeader, err := s.authHeader(c)
 	if err != nil {
-		return fmt.Errorf("failed to create POST request: %v", err)
+		return fmt.Errorf("failed to resolve Sensu Go auth token: %v", err)
 	}
 
-	req.Header.Set("Authorization", c.Token)
-	req.Header.Set("Content-Type", "application/json")
+	timeout := time.Duration(c.Timeout)
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
 
-	ctx, cancel := context.WithTimeout(req.Context(), 1*time.Minute)
-	defer cancel()
-	req = req.WithContext(ctx)
+	var retryAfter time.Duration
+	var lastErr error
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := retryAfter
+			if wait <= 0 {
+				wait = backoffDuration(attempt, time.Duration(c.RetryInitialInterval), time.Duration(c.RetryMaxInterval))
+			}
+			time.Sleep(wait)
+			retryAfter = 0
+		}
 
-	httpClient := &http.Client{}
+		req, err := http.NewRequest("POST", c.URL, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to create POST request: %v", err)
+		}
 
-	resp, err := httpClient.Do(req)
+		req.Header.Set("Authorization", authHeader)
+		req.Header.Set("Content-Type", "application/json")
 
-	if err != nil {
-		s.diag.Error("Failed to POST to Sensu Go", err)
-		return err
-	}
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		req = req.WithContext(ctx)
+
+		resp, err := s.client().Do(req)
+		cancel()
 
-	defer resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			s.diag.Error("Failed to POST to Sensu Go", err)
+			continue
+		}
 
-	if resp.StatusCode/100 != 2 {
-		s.diag.Error(fmt.Sprintf("POST returned non 2xx status code (%d)", resp.StatusCode), err, keyvalue.KV("code", strconv.Itoa(resp.StatusCode)))
+		resp.Body.Close()
+
+		if resp.StatusCode/100 == 2 {
+			return nil
+		}
+
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		lastErr = fmt.Errorf("POST returned non 2xx status code (%d)", resp.StatusCode)
+		s.diag.Error(lastErr.Error(), nil, keyvalue.KV("code", strconv.Itoa(resp.StatusCode)))
+
+		// Only 429 and 5xx responses are worth retrying; other 4xx responses
+		// won't succeed without a different request.
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return lastErr
+		}
 	}
 
-	return nil
+	return lastErr
 }
 
 type HandlerConfig struct {
@@ -202,6 +354,44 @@ type HandlerConfig struct {
 
 	// Tag containing entity name
 	EntityTag string `mapstructure:"entity-tag"`
+
+	// Go template, rendered against the alert event, used for the check
+	// name. Takes precedence over Check when set.
+	CheckTemplate string `mapstructure:"check_template"`
+
+	// Go template, rendered against the alert event, used for the entity
+	// name. Takes precedence over Entity/EntityTag when set.
+	EntityTemplate string `mapstructure:"entity_template"`
+
+	// Go template, rendered against the alert event, used for the
+	// namespace. Takes precedence over Namespace when set.
+	NamespaceTemplate string `mapstructure:"namespace_template"`
+
+	// Go template, rendered against the alert event, used for the check
+	// output. Takes precedence over the alert message when set.
+	MessageTemplate string `mapstructure:"message_template"`
+
+	// Go templates, rendered against the alert event, used to compute
+	// label values. Each rendered value overrides the corresponding
+	// entry in Labels.
+	LabelsTemplate map[string]string `mapstructure:"labels_template"`
+
+	// Check metadata annotations.
+	Annotations map[string]string `mapstructure:"annotations"`
+	// Check interval, in seconds, recorded on the event.
+	Interval int `mapstructure:"interval"`
+	// Check subscriptions recorded on the event.
+	Subscriptions []string `mapstructure:"subscriptions"`
+	// Entity name to substitute for a proxy check.
+	ProxyEntityName string `mapstructure:"proxy-entity-name"`
+	// Whether only one member of a subscription executes the check.
+	RoundRobin bool `mapstructure:"round-robin"`
+	// Check TTL, in seconds, after which Sensu considers it stale.
+	TTL int `mapstructure:"ttl"`
+
+	// How to translate numeric alert fields into check metrics.points:
+	// "none" (default), "prometheus", "influxdb", or "graphite".
+	MetricFormat string `mapstructure:"metric_format"`
 }
 
 // Event that is sent over HTTP POST request to sensu-go backend
@@ -218,47 +408,131 @@ type PostEvent struct {
 		Output   string `json:"output"`
 		Status   int    `json:"status"`
 		Metadata struct {
-			Name   string            `json:"name"`
-			Labels map[string]string `json:"labels"`
+			Name        string            `json:"name"`
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations,omitempty"`
 		} `json:"metadata"`
-		Issued   int64    `json:"issued"`
-		Executed int64    `json:"executed"`
-		Handlers []string `json:"handlers"`
+		Issued          int64    `json:"issued"`
+		Executed        int64    `json:"executed"`
+		Handlers        []string `json:"handlers"`
+		Interval        int      `json:"interval,omitempty"`
+		Subscriptions   []string `json:"subscriptions,omitempty"`
+		ProxyEntityName string   `json:"proxy_entity_name,omitempty"`
+		RoundRobin      bool     `json:"round_robin,omitempty"`
+		TTL             int      `json:"ttl,omitempty"`
 	} `json:"check"`
+	Metrics *Metrics `json:"metrics,omitempty"`
 }
 
 type handler struct {
 	s    *Service
 	c    HandlerConfig
 	diag Diagnostic
+
+	checkTmpl     *template.Template
+	entityTmpl    *template.Template
+	namespaceTmpl *template.Template
+	messageTmpl   *template.Template
+	labelsTmpl    map[string]*template.Template
 }
 
 func (s *Service) Handler(c HandlerConfig, ctx ...keyvalue.T) (alert.Handler, error) {
-	return &handler{
+	h := &handler{
 		s:    s,
 		c:    c,
 		diag: s.diag.WithContext(ctx...),
-	}, nil
+	}
+
+	var err error
+	if h.checkTmpl, err = parseTemplate("check", c.CheckTemplate); err != nil {
+		return nil, fmt.Errorf("invalid check_template: %v", err)
+	}
+	if h.entityTmpl, err = parseTemplate("entity", c.EntityTemplate); err != nil {
+		return nil, fmt.Errorf("invalid entity_template: %v", err)
+	}
+	if h.namespaceTmpl, err = parseTemplate("namespace", c.NamespaceTemplate); err != nil {
+		return nil, fmt.Errorf("invalid namespace_template: %v", err)
+	}
+	if h.messageTmpl, err = parseTemplate("message", c.MessageTemplate); err != nil {
+		return nil, fmt.Errorf("invalid message_template: %v", err)
+	}
+	if len(c.LabelsTemplate) > 0 {
+		h.labelsTmpl = make(map[string]*template.Template, len(c.LabelsTemplate))
+		for name, text := range c.LabelsTemplate {
+			tmpl, err := parseTemplate("label_"+name, text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid labels_template %q: %v", name, err)
+			}
+			h.labelsTmpl[name] = tmpl
+		}
+	}
+
+	return h, nil
+}
+
+// render executes tmpl against event, falling back to fallback if tmpl is
+// nil or fails to execute.
+func (h *handler) render(tmpl *template.Template, fallback string, event alert.Event) string {
+	if tmpl == nil {
+		return fallback
+	}
+	rendered, err := executeTemplate(tmpl, event)
+	if err != nil {
+		h.diag.Error("failed to render sensu go template", err)
+		return fallback
+	}
+	return rendered
 }
 
 func (h *handler) Handle(event alert.Event) {
 	entity := h.c.Entity
-
 	if h.c.Entity == "" && h.c.EntityTag != "" {
 		if e, ok := event.Data.Tags[h.c.EntityTag]; ok {
 			entity = e
 		}
 	}
+	entity = h.render(h.entityTmpl, entity, event)
+
+	check := h.c.Check
+	if check == "" {
+		check = event.State.ID
+	}
+	check = h.render(h.checkTmpl, check, event)
+
+	message := h.render(h.messageTmpl, event.State.Message, event)
+	namespace := h.render(h.namespaceTmpl, h.c.Namespace, event)
+
+	labels := h.c.Labels
+	if len(h.labelsTmpl) > 0 {
+		labels = make(map[string]string, len(h.c.Labels)+len(h.labelsTmpl))
+		for k, v := range h.c.Labels {
+			labels[k] = v
+		}
+		for name, tmpl := range h.labelsTmpl {
+			labels[name] = h.render(tmpl, h.c.Labels[name], event)
+		}
+	}
 
-	if err := h.s.Alert(
-		event.State.ID,
-		entity,
-		event.State.Message,
-		h.c.Namespace,
-		h.c.Handlers,
-		h.c.Labels,
-		event.State.Level,
-	); err != nil {
+	if err := h.s.Alert(AlertRequest{
+		Check:     check,
+		Entity:    entity,
+		Message:   message,
+		Namespace: namespace,
+		Handlers:  h.c.Handlers,
+		Labels:    labels,
+		Level:     event.State.Level,
+
+		Annotations:     h.c.Annotations,
+		Interval:        h.c.Interval,
+		Subscriptions:   h.c.Subscriptions,
+		ProxyEntityName: h.c.ProxyEntityName,
+		RoundRobin:      h.c.RoundRobin,
+		TTL:             h.c.TTL,
+
+		MetricFormat: h.c.MetricFormat,
+		Fields:       event.Data.Fields,
+		Tags:         event.Data.Tags,
+	}); err != nil {
 		h.diag.Error("failed to send event to Sensu Go", err)
 	}
 }
@@ -0,0 +1,42 @@
+package sensugo
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/influxdata/kapacitor/alert"
+)
+
+// templateFuncs are made available to every Sensu Go notification template.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"tag": func(tags map[string]string, name string) string {
+		return tags[name]
+	},
+	"field": func(fields map[string]interface{}, name string) interface{} {
+		return fields[name]
+	},
+	"now": time.Now,
+}
+
+// parseTemplate parses text as a named Sensu Go notification template.
+// An empty text returns a nil template so callers can fall back to a
+// static value.
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// executeTemplate renders tmpl against the full alert event, giving
+// templates access to {{.State}} and {{.Data}} (including tags and fields).
+func executeTemplate(tmpl *template.Template, event alert.Event) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,81 @@
+package sensugo
+
+import (
+	"testing"
+
+	"github.com/influxdata/kapacitor/alert"
+)
+
+func TestParseTemplate_EmptyIsNil(t *testing.T) {
+	tmpl, err := parseTemplate("check", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tmpl != nil {
+		t.Fatalf("expected nil template for empty text, got %v", tmpl)
+	}
+}
+
+func TestParseTemplate_InvalidSyntax(t *testing.T) {
+	if _, err := parseTemplate("check", "{{ .Unterminated"); err == nil {
+		t.Fatal("expected an error parsing malformed template text")
+	}
+}
+
+func TestExecuteTemplate_RendersStateDataAndFuncs(t *testing.T) {
+	tmpl, err := parseTemplate("check", `{{ .State.ID }}-{{ tag .Data.Tags "host" }}-{{ field .Data.Fields "value" }}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	event := alert.Event{
+		State: alert.EventState{ID: "cpu"},
+		Data: alert.Data{
+			Tags:   map[string]string{"host": "serverA"},
+			Fields: map[string]interface{}{"value": 42},
+		},
+	}
+
+	got, err := executeTemplate(tmpl, event)
+	if err != nil {
+		t.Fatalf("unexpected error executing template: %v", err)
+	}
+	if want := "cpu-serverA-42"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestTemplateFuncs_Join(t *testing.T) {
+	join, ok := templateFuncs["join"].(func([]string, string) string)
+	if !ok {
+		t.Fatalf("join func has unexpected type %T", templateFuncs["join"])
+	}
+	if got, want := join([]string{"a", "b", "c"}, ","), "a,b,c"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestHandlerRender_FallsBackOnNilTemplate(t *testing.T) {
+	h := &handler{diag: &testDiagnostic{}}
+	event := alert.Event{State: alert.EventState{ID: "cpu"}}
+
+	if got := h.render(nil, "fallback", event); got != "fallback" {
+		t.Fatalf("got %q, want fallback", got)
+	}
+}
+
+func TestHandlerRender_FallsBackOnExecError(t *testing.T) {
+	// A template referencing a field that doesn't exist on alert.Event
+	// fails at execution time, not parse time.
+	tmpl, err := parseTemplate("check", `{{ .NoSuchField }}`)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	h := &handler{diag: &testDiagnostic{}}
+	event := alert.Event{State: alert.EventState{ID: "cpu"}}
+
+	if got := h.render(tmpl, "fallback", event); got != "fallback" {
+		t.Fatalf("got %q, want fallback", got)
+	}
+}